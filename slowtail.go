@@ -2,13 +2,28 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"container/heap"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/syslog"
 	"math"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,148 +38,1864 @@ const version = "Slow Tail v0.1"
 const doc = `Slow Tail 🐕
 
   Usage:
-    slowtail [--delay=<ms>] [--rewind=<n>] [--interactive] [--porcelain] <file>
+    slowtail [options] [--out=<sink>]... <file>...
     slowtail --help
     slowtail --version
 
+  Pass - as <file> to read from stdin.
+
   Options:
-    --interactive, -i      Interactive mode ( ⬆⬇ to make the flow faster/slower )
-    --porcelain, -p        Human friendly output in interactive mode 🚽
-                           Beware: output shouldn't be used with other commands!
-    --delay=<ms>, -d=<ms>  Delay in milliseconds [default: 250]
-    --rewind=<n>, -r=<n>   Rewind <n> lines back from the end of file [default: 0]
-                           Keep in mind: you can't rewind STDIN but you can skip <n>
-                           lines from the beginning using this option`
+    --interactive, -i          Interactive mode ( ⬆⬇ to make the flow faster/slower )
+    --porcelain, -p            Human friendly output in interactive mode 🚽
+                               Beware: output shouldn't be used with other commands!
+    --delay=<ms>, -d=<ms>      Delay in milliseconds [default: 250]
+    --rewind=<n>, -r=<n>       Rewind <n> lines back from the end of file [default: 0]
+                               Keep in mind: you can't rewind STDIN but you can skip <n>
+                               lines from the beginning using this option
+    --format=<fmt>             Parse each line as a structured record: json, jsonl, logfmt
+                               or csv (the first line is treated as the CSV header)
+    --timestamp-field=<name>   Field holding the record's timestamp; with --replay-realtime
+                               it paces lines by the real delta between them instead of
+                               the fixed --delay, and with --merge-by=timestamp it orders
+                               lines from multiple sources
+    --replay-realtime          Sleep by the actual gap between consecutive timestamps
+                               (requires --timestamp-field)
+    --speed=<factor>           Multiplier applied to the realtime delta [default: 1]
+    --merge-by=<mode>          How to order lines from multiple <file> sources: arrival
+                               (receive order) or timestamp (requires --timestamp-field)
+                               [default: arrival]
+    --follow, -f               Keep watching every source after it reaches EOF instead
+                               of shutting down once all of them have been drained.
+                               Always on when tailing a single <file>; pass this to opt
+                               into the same behavior when merging multiple sources
+    --label=<name>             Prefix lines with this label instead of the filename
+                               (only meaningful with a single <file>)
+    --rules=<file>             Apply regex rules from <file> before emitting each line:
+                               one tab-separated rule per line, FIELD / REGEX / ACTION / ARGS,
+                               where ACTION is one of highlight <color>, drop, only,
+                               delay <ms> or burst <n>. FIELD is empty to match the whole
+                               line, or a structured --format field name
+    --no-color                 Disable highlight colors, same as setting NO_COLOR
+    --serve=<addr>             Serve a browser viewer at <addr> (e.g. :8080) that streams
+                               paced lines over WebSocket, with shared delay/pause/filter
+                               controls broadcast to every connected browser
+    --serve-auth=<token>       Require this token (as ?token= or a Bearer header) to load
+                               the viewer or connect over WebSocket
+    --tls-cert=<path>          TLS certificate for --serve (requires --tls-key)
+    --tls-key=<path>           TLS private key for --serve (requires --tls-cert)
+    --out=<sink>               Send output to this sink instead of stdout; may be repeated
+                               to fan out to several. One of:
+                                 stdout
+                                 file:<path>[,maxsize=<size>][,keep=<n>]
+                                 syslog://<host>:<port>[?facility=<name>]
+                                 http://<url>[?batch=<n>][&batchInterval=<dur>]
+                                 exec:<command>[|batch=<n>]
+                               (stdout if not given)`
+
+const (
+	formatJSON   = "json"
+	formatJSONL  = "jsonl"
+	formatLogfmt = "logfmt"
+	formatCSV    = "csv"
+)
+
+const (
+	mergeByArrival   = "arrival"
+	mergeByTimestamp = "timestamp"
+)
 
 type arguments struct {
 	rewindLines       int
 	delayMilliseconds int
-	filePath          string
+	filePaths         []string
 	porcelain         bool
 	interactive       bool
+	format            string
+	timestampField    string
+	replayRealtime    bool
+	speed             float64
+	mergeBy           string
+	follow            bool
+	label             string
+	rulesPath         string
+	noColor           bool
+	rules             *rules
+	serveAddr         string
+	serveAuth         string
+	tlsCert           string
+	tlsKey            string
+	outSpecs          []string
 }
 
+// csvHeaders caches the parsed CSV header per source path, since concurrently
+// tailed files may have different schemas and a single shared header would
+// cross-wire their fields.
+var csvHeaders = map[string][]string{}
+var csvHeadersMutex = &sync.Mutex{}
+
 var globalDelay = 0
 var globalDelayMutex = &sync.Mutex{}
 
 func main() {
 	linesChannel := make(chan string, 1)
 	readyChannel := make(chan bool, 1)
+	doneChannel := make(chan bool, 1)
 
 	args, _ := docopt.Parse(doc, nil, true, version, false)
 	options, err := parseArgs(args)
 
 	if err != nil {
-		checkErr(err)
+		checkErr(err)
+	}
+
+	globalDelay = options.delayMilliseconds
+
+	if options.noColor || os.Getenv("NO_COLOR") != "" {
+		color.NoColor = true
+	}
+
+	if options.rulesPath != "" {
+		loadedRules, err := loadRules(options.rulesPath)
+
+		if err != nil {
+			checkErr(err)
+		}
+
+		options.rules = loadedRules
+	}
+
+	richUI := options.interactive && !options.porcelain
+
+	switch {
+	case richUI:
+		go interactiveMode(&readyChannel, &linesChannel, &doneChannel)
+	case options.interactive:
+		go interactivePorcelainMode(&readyChannel, options.porcelain)
+	default:
+		readyChannel <- true
+	}
+
+	if <-readyChannel {
+		go multiFileToChan(options.filePaths, &linesChannel, options)
+	}
+
+	var hub *wsHub
+
+	if options.serveAddr != "" {
+		hub = newWSHub()
+		go hub.run()
+		go serveViewer(options, hub)
+	}
+
+	sinks, err := buildSinks(options.outSpecs)
+
+	if err != nil {
+		checkErr(err)
+	}
+
+	if richUI {
+		<-doneChannel
+		return
+	}
+
+	for line := range linesChannel {
+		for _, sink := range sinks {
+			sink.send(line)
+		}
+
+		if hub != nil {
+			hub.broadcastLine(line)
+		}
+	}
+
+	for _, sink := range sinks {
+		sink.shutdown()
+	}
+}
+
+func parseArgs(args map[string]interface{}) (arguments, error) {
+	rewindLines := int(0)
+	delayMilliseconds := int(250)
+	filePaths := []string{}
+	porcelain := false
+	interactive := false
+
+	if rewindArg, ok := args["--rewind"].(string); ok {
+		rewindLines, _ = strconv.Atoi(rewindArg)
+	}
+
+	if porcelainArg, ok := args["--porcelain"].(bool); ok {
+		porcelain = porcelainArg
+	}
+
+	if interactiveArg, ok := args["--interactive"].(bool); ok {
+		interactive = interactiveArg
+	}
+
+	if rewindLines < 0 || rewindLines > math.MaxInt32 {
+		return arguments{}, errors.New("--rewind must be a positive number of lines")
+	}
+
+	if delayArg, ok := args["--delay"].(string); ok {
+		delayMilliseconds, _ = strconv.Atoi(delayArg)
+	}
+
+	if delayMilliseconds < 0 || delayMilliseconds > math.MaxInt32 {
+		return arguments{}, errors.New("--delay must be a positive number of milliseconds")
+	}
+
+	if filePathArgs, ok := args["<file>"].([]string); ok {
+		filePaths = filePathArgs
+	}
+
+	mergeBy := mergeByArrival
+	if mergeByArg, ok := args["--merge-by"].(string); ok && mergeByArg != "" {
+		mergeBy = mergeByArg
+	}
+
+	if mergeBy != mergeByArrival && mergeBy != mergeByTimestamp {
+		return arguments{}, errors.New("--merge-by must be one of: arrival, timestamp")
+	}
+
+	follow := len(filePaths) <= 1
+	if followArg, ok := args["--follow"].(bool); ok && followArg {
+		follow = true
+	}
+
+	label := ""
+	if labelArg, ok := args["--label"].(string); ok {
+		label = labelArg
+	}
+
+	format := ""
+	if formatArg, ok := args["--format"].(string); ok {
+		format = formatArg
+	}
+
+	if format != "" && format != formatJSON && format != formatJSONL && format != formatLogfmt && format != formatCSV {
+		return arguments{}, errors.New("--format must be one of: json, jsonl, logfmt, csv")
+	}
+
+	timestampField := ""
+	if timestampFieldArg, ok := args["--timestamp-field"].(string); ok {
+		timestampField = timestampFieldArg
+	}
+
+	replayRealtime := false
+	if replayRealtimeArg, ok := args["--replay-realtime"].(bool); ok {
+		replayRealtime = replayRealtimeArg
+	}
+
+	if replayRealtime && timestampField == "" {
+		return arguments{}, errors.New("--replay-realtime requires --timestamp-field")
+	}
+
+	if mergeBy == mergeByTimestamp && timestampField == "" {
+		return arguments{}, errors.New("--merge-by=timestamp requires --timestamp-field")
+	}
+
+	speed := float64(1)
+	if speedArg, ok := args["--speed"].(string); ok {
+		parsedSpeed, err := strconv.ParseFloat(speedArg, 64)
+
+		if err != nil || parsedSpeed <= 0 {
+			return arguments{}, errors.New("--speed must be a positive number")
+		}
+
+		speed = parsedSpeed
+	}
+
+	rulesPath := ""
+	if rulesArg, ok := args["--rules"].(string); ok {
+		rulesPath = rulesArg
+	}
+
+	noColor := false
+	if noColorArg, ok := args["--no-color"].(bool); ok {
+		noColor = noColorArg
+	}
+
+	serveAddr := ""
+	if serveArg, ok := args["--serve"].(string); ok {
+		serveAddr = serveArg
+	}
+
+	serveAuth := ""
+	if serveAuthArg, ok := args["--serve-auth"].(string); ok {
+		serveAuth = serveAuthArg
+	}
+
+	tlsCert := ""
+	if tlsCertArg, ok := args["--tls-cert"].(string); ok {
+		tlsCert = tlsCertArg
+	}
+
+	tlsKey := ""
+	if tlsKeyArg, ok := args["--tls-key"].(string); ok {
+		tlsKey = tlsKeyArg
+	}
+
+	if (tlsCert == "") != (tlsKey == "") {
+		return arguments{}, errors.New("--tls-cert and --tls-key must be set together")
+	}
+
+	outSpecs := []string{}
+	if outArgs, ok := args["--out"].([]string); ok {
+		outSpecs = outArgs
+	}
+
+	if interactive && !porcelain && (len(outSpecs) > 0 || serveAddr != "") {
+		return arguments{}, errors.New("--interactive cannot be combined with --out or --serve: the TUI consumes every line itself")
+	}
+
+	return arguments{
+		rewindLines,
+		delayMilliseconds,
+		filePaths,
+		porcelain,
+		interactive,
+		format,
+		timestampField,
+		replayRealtime,
+		speed,
+		mergeBy,
+		follow,
+		label,
+		rulesPath,
+		noColor,
+		nil,
+		serveAddr,
+		serveAuth,
+		tlsCert,
+		tlsKey,
+		outSpecs,
+	}, nil
+}
+
+// sourceColors cycles a fixed palette across tailed sources so each one stays
+// visually distinct without any configuration.
+var sourceColors = []*color.Color{
+	color.New(color.FgCyan),
+	color.New(color.FgMagenta),
+	color.New(color.FgYellow),
+	color.New(color.FgGreen),
+	color.New(color.FgBlue),
+	color.New(color.FgRed),
+}
+
+type source struct {
+	path  string
+	label string
+	color *color.Color
+}
+
+func newSource(path string, index int, label string) source {
+	resolvedLabel := label
+
+	if resolvedLabel == "" {
+		if path == "-" {
+			resolvedLabel = "stdin"
+		} else {
+			resolvedLabel = filepath.Base(path)
+		}
+	}
+
+	return source{
+		path:  path,
+		label: resolvedLabel,
+		color: sourceColors[index%len(sourceColors)],
+	}
+}
+
+func (s source) prefix(text string) string {
+	return s.color.Sprintf("[%s]", s.label) + " " + text
+}
+
+// taggedLine is a line in flight from one source, carrying enough to sort it
+// against lines from other sources when --merge-by=timestamp is requested.
+type taggedLine struct {
+	text  string
+	ts    time.Time
+	hasTS bool
+}
+
+// multiFileToChan tails every path in paths concurrently (optionally including
+// stdin via the "-" pseudo-path), tags each line with its source's color and
+// label, and fans everything into linesChannel either as it arrives or, with
+// --merge-by=timestamp, reordered by each record's parsed timestamp.
+func multiFileToChan(paths []string, linesChannel *chan string, options arguments) {
+	defer close(*linesChannel)
+
+	rawChannel := make(chan taggedLine, 64)
+	var wg sync.WaitGroup
+
+	singleLabel := options.label
+	if len(paths) > 1 {
+		singleLabel = ""
+	}
+
+	for i, path := range paths {
+		wg.Add(1)
+		src := newSource(path, i, singleLabel)
+
+		go func(path string, src source) {
+			defer wg.Done()
+			sourceToChan(path, &rawChannel, src, options)
+		}(path, src)
+	}
+
+	go func() {
+		wg.Wait()
+		close(rawChannel)
+	}()
+
+	if options.mergeBy == mergeByTimestamp {
+		mergeByTimestampToChan(&rawChannel, linesChannel)
+	} else {
+		for line := range rawChannel {
+			*linesChannel <- line.text
+		}
+	}
+}
+
+// sourceToChan tails a single source (a file, or stdin when path is "-") and
+// pushes prefixed, paced lines onto rawChannel.
+func sourceToChan(path string, rawChannel *chan taggedLine, src source, options arguments) {
+	var lastTimestamp *time.Time
+	ruleState := &ruleState{}
+	unpacedLines := 0
+
+	emit := func(line string) {
+		text, keep, overrideDelay := applyRules(line, options.format, options.rules, ruleState, path)
+
+		if !keep {
+			return
+		}
+
+		ts, hasTS := recordTimestamp(line, options.format, options.timestampField, path)
+		*rawChannel <- taggedLine{text: src.prefix(text), ts: ts, hasTS: hasTS}
+
+		if unpacedLines > 0 {
+			unpacedLines--
+			return
+		}
+
+		if overrideDelay != nil {
+			sleepMilliseconds(*overrideDelay)
+		} else {
+			sleepForLine(line, options, &lastTimestamp, path)
+		}
+	}
+
+	if path == "-" {
+		// stdin can't be rewound: its first rewindLines lines go out without a delay instead.
+		unpacedLines = options.rewindLines
+		scanner := bufio.NewScanner(os.Stdin)
+
+		for scanner.Scan() {
+			emit(scanner.Text())
+		}
+
+		return
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		checkErr(err)
+	}
+
+	if options.format == formatCSV {
+		loadCSVHeader(path)
+	}
+
+	if options.rewindLines > 0 {
+		tailFile(path, options.rewindLines, emit)
+	}
+
+	t, err := tail.TailFile(path, tail.Config{
+		Follow:   options.follow,
+		Poll:     true,
+		Location: &tail.SeekInfo{Offset: 0, Whence: 2},
+		Logger:   tail.DiscardingLogger,
+	})
+
+	if err != nil {
+		checkErr(err)
+	}
+
+	for line := range t.Lines {
+		emit(line.Text)
+	}
+}
+
+// reorderWindow is how long mergeByTimestampToChan waits for a potentially
+// earlier line from a slower source before flushing what it's buffered.
+const reorderWindow = 2 * time.Second
+
+// taggedLineHeap is a min-heap of taggedLine ordered by timestamp, used to pop
+// the globally-earliest buffered line across all sources.
+type taggedLineHeap []taggedLine
+
+func (h taggedLineHeap) Len() int            { return len(h) }
+func (h taggedLineHeap) Less(i, j int) bool  { return h[i].ts.Before(h[j].ts) }
+func (h taggedLineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *taggedLineHeap) Push(x interface{}) { *h = append(*h, x.(taggedLine)) }
+
+func (h *taggedLineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// mergeByTimestampToChan buffers lines from every source in a min-heap keyed
+// on timestamp and periodically flushes those older than reorderWindow in
+// timestamp order, trading a small amount of latency for correct interleaving
+// across sources whose clocks or arrival rates drift apart. Lines without a
+// parseable timestamp are flushed immediately, in arrival order, since there
+// is nothing to sort them by.
+func mergeByTimestampToChan(rawChannel *chan taggedLine, linesChannel *chan string) {
+	buffer := &taggedLineHeap{}
+	ticker := time.NewTicker(reorderWindow / 2)
+	defer ticker.Stop()
+
+	flush := func(all bool) {
+		cutoff := time.Now().Add(-reorderWindow)
+
+		for buffer.Len() > 0 && (all || (*buffer)[0].ts.Before(cutoff)) {
+			*linesChannel <- heap.Pop(buffer).(taggedLine).text
+		}
+	}
+
+	for {
+		select {
+		case line, ok := <-*rawChannel:
+			if !ok {
+				flush(true)
+				return
+			}
+
+			if line.hasTS {
+				heap.Push(buffer, line)
+			} else {
+				*linesChannel <- line.text
+			}
+		case <-ticker.C:
+			flush(false)
+		}
+	}
+}
+
+// sleepForLine picks the pacing strategy for a single line: when the caller asked
+// for realtime replay and the record carries a parseable timestamp field, it sleeps
+// by the delta since lastTimestamp (scaled by --speed); otherwise it falls back to
+// the fixed globalDelay.
+func sleepForLine(line string, options arguments, lastTimestamp **time.Time, headerKey string) {
+	if options.replayRealtime && options.timestampField != "" {
+		if ts, ok := recordTimestamp(line, options.format, options.timestampField, headerKey); ok {
+			if *lastTimestamp != nil {
+				delta := ts.Sub(**lastTimestamp)
+
+				if delta > 0 {
+					time.Sleep(time.Duration(float64(delta) * options.speed))
+				}
+			}
+
+			*lastTimestamp = &ts
+			return
+		}
+	}
+
+	globalDelayMutex.Lock()
+	delay := globalDelay
+	globalDelayMutex.Unlock()
+
+	sleepMilliseconds(delay)
+}
+
+// recordTimestamp parses line according to format and returns the value of field
+// as a time.Time. ok is false when the format is unrecognised, the line can't be
+// parsed, the field is missing, or its value isn't a timestamp slowtail understands.
+func recordTimestamp(line string, format string, field string, headerKey string) (time.Time, bool) {
+	record, ok := parseRecord(line, format, headerKey)
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	raw, ok := record[field]
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return parseTimestampValue(raw)
+}
+
+// parseRecord decodes a single line into a flat field map according to format.
+// CSV is stateful: it's mapped using the header cached for headerKey (the
+// source path), since concurrently tailed sources may have different schemas.
+func parseRecord(line string, format string, headerKey string) (map[string]string, bool) {
+	switch format {
+	case formatJSON, formatJSONL:
+		return parseJSONRecord(line)
+	case formatLogfmt:
+		return parseLogfmtRecord(line), true
+	case formatCSV:
+		return parseCSVRecord(line, headerKey)
+	default:
+		return nil, false
+	}
+}
+
+func parseJSONRecord(line string) (map[string]string, bool) {
+	var raw map[string]interface{}
+
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, false
+	}
+
+	record := make(map[string]string, len(raw))
+
+	for key, value := range raw {
+		record[key] = fmt.Sprintf("%v", value)
+	}
+
+	return record, true
+}
+
+func parseLogfmtRecord(line string) map[string]string {
+	record := make(map[string]string)
+
+	for _, token := range strings.Fields(line) {
+		key, value, found := strings.Cut(token, "=")
+
+		if !found {
+			continue
+		}
+
+		record[key] = strings.Trim(value, `"`)
+	}
+
+	return record
+}
+
+func parseCSVRecord(line string, headerKey string) (map[string]string, bool) {
+	reader := csv.NewReader(strings.NewReader(line))
+	fields, err := reader.Read()
+
+	if err != nil {
+		return nil, false
+	}
+
+	csvHeadersMutex.Lock()
+	header, ok := csvHeaders[headerKey]
+
+	if !ok {
+		csvHeaders[headerKey] = fields
+		csvHeadersMutex.Unlock()
+		return nil, false
+	}
+	csvHeadersMutex.Unlock()
+
+	record := make(map[string]string, len(fields))
+
+	for i, value := range fields {
+		if i < len(header) {
+			record[header[i]] = value
+		}
+	}
+
+	return record, true
+}
+
+// loadCSVHeader reads the first line straight from the start of path and
+// caches it under path (the headerKey parseRecord is given for that source),
+// independently of wherever the tail follow
+// point ends up starting.
+func loadCSVHeader(path string) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	if !scanner.Scan() {
+		return
+	}
+
+	reader := csv.NewReader(strings.NewReader(scanner.Text()))
+	fields, err := reader.Read()
+
+	if err != nil {
+		return
+	}
+
+	csvHeadersMutex.Lock()
+	defer csvHeadersMutex.Unlock()
+
+	if _, ok := csvHeaders[path]; !ok {
+		csvHeaders[path] = fields
+	}
+}
+
+// parseTimestampValue tries, in order, RFC3339(Nano) and a Unix epoch in seconds
+// (fractional seconds allowed), since those cover both human logfmt/JSON timestamps
+// and the epoch floats common in machine-generated JSON lines.
+func parseTimestampValue(raw string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t, true
+	}
+
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		wholeSeconds := int64(seconds)
+		nanoseconds := int64((seconds - float64(wholeSeconds)) * float64(time.Second))
+
+		return time.Unix(wholeSeconds, nanoseconds), true
+	}
+
+	return time.Time{}, false
+}
+
+type ruleAction int
+
+const (
+	ruleHighlight ruleAction = iota
+	ruleDrop
+	ruleOnly
+	ruleDelay
+	ruleBurst
+)
+
+// rule is one line of a --rules file: match pattern against field (the whole
+// line when field is empty), then apply action.
+type rule struct {
+	field     string
+	pattern   *regexp.Regexp
+	action    ruleAction
+	colorName string
+	delayMs   int
+	burstN    int
+}
+
+// rules is a parsed --rules file. hasOnly caches whether any "only" rule is
+// present, since its presence flips the default from keep-everything to
+// drop-unless-matched.
+type rules struct {
+	list    []rule
+	hasOnly bool
+}
+
+// ruleState carries the one piece of state a rule file can accumulate across
+// lines from a single source: how many more lines a "burst" rule should let
+// through without a delay.
+type ruleState struct {
+	burstRemaining int
+}
+
+var ruleColors = map[string]*color.Color{
+	"red":     color.New(color.FgRed),
+	"green":   color.New(color.FgGreen),
+	"yellow":  color.New(color.FgYellow),
+	"blue":    color.New(color.FgBlue),
+	"magenta": color.New(color.FgMagenta),
+	"cyan":    color.New(color.FgCyan),
+	"white":   color.New(color.FgWhite),
+}
+
+// loadRules reads a --rules file: one tab-separated rule per line, as
+// FIELD\tREGEX\tACTION\tARGS. Blank lines and lines starting with # are
+// skipped.
+func loadRules(path string) (*rules, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var list []rule
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+
+	for scanner.Scan() {
+		lineNum++
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		r, err := parseRuleLine(raw)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+
+		list = append(list, r)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	hasOnly := false
+
+	for _, r := range list {
+		if r.action == ruleOnly {
+			hasOnly = true
+		}
+	}
+
+	return &rules{list: list, hasOnly: hasOnly}, nil
+}
+
+func parseRuleLine(raw string) (rule, error) {
+	fields := strings.Split(raw, "\t")
+
+	if len(fields) < 3 {
+		return rule{}, errors.New("expected FIELD\\tREGEX\\tACTION[\\tARGS]")
+	}
+
+	pattern, err := regexp.Compile(fields[1])
+
+	if err != nil {
+		return rule{}, err
+	}
+
+	arg := ""
+	if len(fields) > 3 {
+		arg = strings.TrimSpace(fields[3])
+	}
+
+	r := rule{field: strings.TrimSpace(fields[0]), pattern: pattern}
+
+	switch strings.ToLower(strings.TrimSpace(fields[2])) {
+	case "highlight":
+		r.action = ruleHighlight
+		r.colorName = strings.ToLower(arg)
+	case "drop":
+		r.action = ruleDrop
+	case "only":
+		r.action = ruleOnly
+	case "delay":
+		ms, err := strconv.Atoi(arg)
+
+		if err != nil {
+			return rule{}, fmt.Errorf("delay needs a millisecond arg: %w", err)
+		}
+
+		r.action = ruleDelay
+		r.delayMs = ms
+	case "burst":
+		n, err := strconv.Atoi(arg)
+
+		if err != nil {
+			return rule{}, fmt.Errorf("burst needs a line-count arg: %w", err)
+		}
+
+		r.action = ruleBurst
+		r.burstN = n
+	default:
+		return rule{}, fmt.Errorf("unknown rule action %q", fields[2])
+	}
+
+	return r, nil
+}
+
+func (r rule) matches(line string, format string, headerKey string) bool {
+	if r.field == "" {
+		return r.pattern.MatchString(line)
+	}
+
+	record, ok := parseRecord(line, format, headerKey)
+
+	if !ok {
+		return false
+	}
+
+	value, ok := record[r.field]
+
+	if !ok {
+		return false
+	}
+
+	return r.pattern.MatchString(value)
+}
+
+// applyRules runs line through rs in order and returns the text to emit (with
+// any "highlight" colors applied), whether to emit it at all, and an override
+// for how long to sleep afterwards (nil means fall back to the normal pacing).
+func applyRules(line string, format string, rs *rules, state *ruleState, headerKey string) (string, bool, *int) {
+	if rs == nil {
+		return line, true, nil
+	}
+
+	text := line
+	emit := !rs.hasOnly
+	var overrideDelay *int
+
+	if state.burstRemaining > 0 {
+		state.burstRemaining--
+		noDelay := 0
+		overrideDelay = &noDelay
+	}
+
+	for _, r := range rs.list {
+		if !r.matches(line, format, headerKey) {
+			continue
+		}
+
+		switch r.action {
+		case ruleHighlight:
+			if c, ok := ruleColors[r.colorName]; ok {
+				text = c.Sprint(text)
+			}
+		case ruleDrop:
+			return text, false, overrideDelay
+		case ruleOnly:
+			emit = true
+		case ruleDelay:
+			delayMs := r.delayMs
+			overrideDelay = &delayMs
+		case ruleBurst:
+			state.burstRemaining = r.burstN
+			noDelay := 0
+			overrideDelay = &noDelay
+		}
+	}
+
+	return text, emit, overrideDelay
+}
+
+// servePaused and serveFilter are the shared control state for --serve: any
+// connected browser can change them, and the new value is broadcast back to
+// every client so all viewers of a session stay in sync.
+var servePaused = false
+var servePauseMutex = &sync.Mutex{}
+
+var serveFilter *regexp.Regexp
+var serveFilterMutex = &sync.Mutex{}
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsConn is a hand-rolled RFC 6455 connection: just enough framing to send
+// text frames to the browser and read the small JSON control messages it
+// sends back, without pulling in a WebSocket dependency for one feature.
+type wsConn struct {
+	conn    net.Conn
+	rw      *bufio.ReadWriter
+	writeMu sync.Mutex
+}
+
+// maxWSFrameLength bounds a single client frame's declared payload length.
+// The viewer only ever sends small single-object JSON control messages, so
+// this is generous headroom; it exists to stop a client's claimed length
+// (up to 2^64-1 via the extended-length path) from driving an unbounded
+// allocation before the payload is even read.
+const maxWSFrameLength = 1 << 20
+
+func wsHandshake(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+
+	if !ok {
+		return nil, errors.New("connection does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err != nil {
+		return nil, err
+	}
+
+	accept := sha1.Sum([]byte(key + wsGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n\r\n"
+
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+// readMessage reads one unfragmented client frame, transparently answering
+// pings, and returns its payload as text. It does not support fragmented
+// messages, which is fine for the small single-object JSON control messages
+// this viewer sends.
+func (c *wsConn) readMessage() (string, error) {
+	header := make([]byte, 2)
+
+	if _, err := io.ReadFull(c.rw, header); err != nil {
+		return "", err
+	}
+
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return "", err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+
+		if _, err := io.ReadFull(c.rw, ext); err != nil {
+			return "", err
+		}
+
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if length > maxWSFrameLength {
+		return "", fmt.Errorf("frame length %d exceeds the %d byte limit", length, maxWSFrameLength)
+	}
+
+	var maskKey [4]byte
+
+	if masked {
+		if _, err := io.ReadFull(c.rw, maskKey[:]); err != nil {
+			return "", err
+		}
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return "", err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	switch opcode {
+	case 0x8:
+		return "", io.EOF
+	case 0x9:
+		if err := c.writeFrame(0xA, payload); err != nil {
+			return "", err
+		}
+
+		return c.readMessage()
+	case 0xA:
+		return c.readMessage()
+	default:
+		return string(payload), nil
+	}
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	length := len(payload)
+
+	switch {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 65535:
+		ext := make([]byte, 2)
+		binary.BigEndian.PutUint16(ext, uint16(length))
+		header = append(header, 126)
+		header = append(header, ext...)
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(length))
+		header = append(header, 127)
+		header = append(header, ext...)
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeText(text string) error {
+	return c.writeFrame(0x1, []byte(text))
+}
+
+func (c *wsConn) close() {
+	c.writeFrame(0x8, nil)
+	c.conn.Close()
+}
+
+// wsHub fans broadcast lines and control-state updates out to every connected
+// viewer, and applies control messages any one of them sends.
+type wsHub struct {
+	mu          sync.Mutex
+	clients     map[*wsConn]bool
+	register    chan *wsConn
+	unregister  chan *wsConn
+	broadcastCh chan string
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{
+		clients:     make(map[*wsConn]bool),
+		register:    make(chan *wsConn),
+		unregister:  make(chan *wsConn),
+		broadcastCh: make(chan string, 64),
+	}
+}
+
+func (h *wsHub) run() {
+	for {
+		select {
+		case c := <-h.register:
+			h.mu.Lock()
+			h.clients[c] = true
+			h.mu.Unlock()
+		case c := <-h.unregister:
+			h.mu.Lock()
+			delete(h.clients, c)
+			h.mu.Unlock()
+			c.close()
+		case msg := <-h.broadcastCh:
+			h.mu.Lock()
+			for c := range h.clients {
+				if err := c.writeText(msg); err != nil {
+					delete(h.clients, c)
+					go c.close()
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// broadcastLine forwards line to every connected viewer, unless the shared
+// control state is paused or the line doesn't match the shared filter.
+func (h *wsHub) broadcastLine(line string) {
+	servePauseMutex.Lock()
+	paused := servePaused
+	servePauseMutex.Unlock()
+
+	if paused {
+		return
+	}
+
+	serveFilterMutex.Lock()
+	filter := serveFilter
+	serveFilterMutex.Unlock()
+
+	if filter != nil && !filter.MatchString(line) {
+		return
+	}
+
+	payload, err := json.Marshal(map[string]string{"type": "line", "text": line})
+
+	if err != nil {
+		return
+	}
+
+	h.broadcastCh <- string(payload)
+}
+
+func currentServeState() map[string]interface{} {
+	globalDelayMutex.Lock()
+	delay := globalDelay
+	globalDelayMutex.Unlock()
+
+	servePauseMutex.Lock()
+	paused := servePaused
+	servePauseMutex.Unlock()
+
+	serveFilterMutex.Lock()
+	filterPattern := ""
+	if serveFilter != nil {
+		filterPattern = serveFilter.String()
+	}
+	serveFilterMutex.Unlock()
+
+	return map[string]interface{}{
+		"type":    "state",
+		"delayMs": delay,
+		"paused":  paused,
+		"filter":  filterPattern,
+	}
+}
+
+func (h *wsHub) broadcastState() {
+	payload, err := json.Marshal(currentServeState())
+
+	if err != nil {
+		return
+	}
+
+	h.broadcastCh <- string(payload)
+}
+
+// serveControlMessage is what a connected browser sends back over the
+// WebSocket to change the shared delay, pause, or filter state.
+type serveControlMessage struct {
+	Type    string `json:"type"`
+	DelayMs int    `json:"delayMs"`
+	Paused  bool   `json:"paused"`
+	Filter  string `json:"filter"`
+}
+
+// handleClient registers c, brings it up to date with the current shared
+// state, then applies whatever control messages it sends until it disconnects.
+func (h *wsHub) handleClient(c *wsConn) {
+	h.register <- c
+	defer func() { h.unregister <- c }()
+
+	initial, err := json.Marshal(currentServeState())
+
+	if err == nil {
+		c.writeText(string(initial))
+	}
+
+	for {
+		msg, err := c.readMessage()
+
+		if err != nil {
+			return
+		}
+
+		var ctrl serveControlMessage
+
+		if err := json.Unmarshal([]byte(msg), &ctrl); err != nil {
+			continue
+		}
+
+		switch ctrl.Type {
+		case "setDelay":
+			globalDelayMutex.Lock()
+			globalDelay = ctrl.DelayMs
+			globalDelayMutex.Unlock()
+		case "pause":
+			servePauseMutex.Lock()
+			servePaused = ctrl.Paused
+			servePauseMutex.Unlock()
+		case "setFilter":
+			serveFilterMutex.Lock()
+			if ctrl.Filter == "" {
+				serveFilter = nil
+			} else if re, err := regexp.Compile(ctrl.Filter); err == nil {
+				serveFilter = re
+			}
+			serveFilterMutex.Unlock()
+		}
+
+		h.broadcastState()
+	}
+}
+
+func serveAuthorized(options arguments, r *http.Request) bool {
+	if options.serveAuth == "" {
+		return true
+	}
+
+	if token := r.URL.Query().Get("token"); token == options.serveAuth {
+		return true
+	}
+
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") == options.serveAuth
+}
+
+// viewerHTML is the single-page browser viewer for --serve: a scrolling log
+// fed by the WebSocket, plus inputs that send control messages for delay,
+// pause, and filter back to the hub.
+const viewerHTML = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Slow Tail</title>
+<style>
+  body { background: #111; color: #ddd; font-family: monospace; margin: 0; }
+  #controls { padding: 8px; background: #222; position: sticky; top: 0; }
+  #log { height: 85vh; overflow-y: auto; padding: 8px; white-space: pre-wrap; }
+  input, button { font-family: monospace; }
+</style>
+</head>
+<body>
+  <div id="controls">
+    delay(ms): <input id="delay" type="number" style="width:80px">
+    <label><input id="paused" type="checkbox"> paused</label>
+    filter: <input id="filter" type="text" style="width:200px">
+    <button onclick="applyControls()">apply</button>
+  </div>
+  <div id="log"></div>
+  <script>
+    var proto = location.protocol === "https:" ? "wss://" : "ws://";
+    var token = new URLSearchParams(location.search).get("token");
+    var url = proto + location.host + "/ws" + (token ? ("?token=" + encodeURIComponent(token)) : "");
+    var ws = new WebSocket(url);
+    var log = document.getElementById("log");
+
+    ws.onmessage = function(event) {
+      var msg = JSON.parse(event.data);
+
+      if (msg.type === "line") {
+        var line = document.createElement("div");
+        line.textContent = msg.text;
+        log.appendChild(line);
+        log.scrollTop = log.scrollHeight;
+      } else if (msg.type === "state") {
+        document.getElementById("delay").value = msg.delayMs;
+        document.getElementById("paused").checked = msg.paused;
+        document.getElementById("filter").value = msg.filter;
+      }
+    };
+
+    function applyControls() {
+      ws.send(JSON.stringify({type: "setDelay", delayMs: parseInt(document.getElementById("delay").value, 10) || 0}));
+      ws.send(JSON.stringify({type: "pause", paused: document.getElementById("paused").checked}));
+      ws.send(JSON.stringify({type: "setFilter", filter: document.getElementById("filter").value}));
+    }
+  </script>
+</body>
+</html>`
+
+// serveViewer starts the embedded HTTP(S) server for --serve: the viewer page
+// at "/" and the WebSocket endpoint at "/ws", both gated by --serve-auth when set.
+func serveViewer(options arguments, hub *wsHub) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !serveAuthorized(options, r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, viewerHTML)
+	})
+
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		if !serveAuthorized(options, r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := wsHandshake(w, r)
+
+		if err != nil {
+			return
+		}
+
+		hub.handleClient(conn)
+	})
+
+	server := &http.Server{Addr: options.serveAddr, Handler: mux}
+
+	var err error
+
+	if options.tlsCert != "" {
+		err = server.ListenAndServeTLS(options.tlsCert, options.tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if err != nil && err != http.ErrServerClosed {
+		checkErr(err)
+	}
+}
+
+// sinkRunner owns an unbounded in-memory queue and a dedicated pump
+// goroutine, so one stuck or slow sink (e.g. "exec:sleep 100000") only
+// backs up its own queue instead of blocking send, which main's shared
+// dispatch loop calls for every sink in turn, and which would otherwise
+// stall delivery to every other sink and the upstream tailing goroutines
+// behind it too.
+type sinkRunner struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []string
+	closed bool
+	done   chan bool
+	write  func(line string) error
+	close  func() error
+}
+
+func startSink(write func(line string) error, closeFn func() error) *sinkRunner {
+	sr := &sinkRunner{
+		done:  make(chan bool, 1),
+		write: write,
+		close: closeFn,
+	}
+	sr.cond = sync.NewCond(&sr.mu)
+
+	go sr.pump()
+
+	return sr
+}
+
+// pump applies the sink's own backpressure: it blocks on write for as long
+// as the sink needs, but only ever blocks itself, since send never waits on
+// it.
+func (sr *sinkRunner) pump() {
+	for {
+		sr.mu.Lock()
+
+		for len(sr.queue) == 0 && !sr.closed {
+			sr.cond.Wait()
+		}
+
+		if len(sr.queue) == 0 {
+			sr.mu.Unlock()
+			break
+		}
+
+		line := sr.queue[0]
+		sr.queue = sr.queue[1:]
+		sr.mu.Unlock()
+
+		if err := sr.write(line); err != nil {
+			fmt.Fprintln(os.Stderr, "slowtail: sink error:", err)
+		}
+	}
+
+	if sr.close != nil {
+		if err := sr.close(); err != nil {
+			fmt.Fprintln(os.Stderr, "slowtail: sink close error:", err)
+		}
+	}
+
+	sr.done <- true
+}
+
+// send appends line to the sink's queue and returns immediately; it never
+// waits on the sink's own pump goroutine.
+func (sr *sinkRunner) send(line string) {
+	sr.mu.Lock()
+	sr.queue = append(sr.queue, line)
+	sr.mu.Unlock()
+	sr.cond.Signal()
+}
+
+func (sr *sinkRunner) shutdown() {
+	sr.mu.Lock()
+	sr.closed = true
+	sr.mu.Unlock()
+	sr.cond.Signal()
+
+	<-sr.done
+}
+
+func buildSinks(specs []string) ([]*sinkRunner, error) {
+	if len(specs) == 0 {
+		specs = []string{"stdout"}
+	}
+
+	sinks := make([]*sinkRunner, 0, len(specs))
+
+	for _, spec := range specs {
+		sink, err := buildSink(spec)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sink)
+	}
+
+	return sinks, nil
+}
+
+func buildSink(spec string) (*sinkRunner, error) {
+	switch {
+	case spec == "stdout":
+		return newStdoutSink(), nil
+	case strings.HasPrefix(spec, "file:"):
+		return newFileSink(strings.TrimPrefix(spec, "file:"))
+	case strings.HasPrefix(spec, "syslog://"):
+		return newSyslogSink(strings.TrimPrefix(spec, "syslog://"))
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return newHTTPSink(spec)
+	case strings.HasPrefix(spec, "exec:"):
+		return newExecSink(strings.TrimPrefix(spec, "exec:"))
+	default:
+		return nil, fmt.Errorf("unknown --out sink %q", spec)
+	}
+}
+
+func newStdoutSink() *sinkRunner {
+	return startSink(func(line string) error {
+		fmt.Println(line)
+		return nil
+	}, nil)
+}
+
+const defaultMaxFileSize = 10 * 1024 * 1024
+const defaultKeepFiles = 5
+
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &rotatingFile{path: path, maxSize: maxSize, keep: keep, file: file, size: info.Size()}, nil
+}
+
+func (rf *rotatingFile) writeLine(line string) error {
+	if rf.maxSize > 0 && rf.size >= rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := fmt.Fprintln(rf.file, line)
+	rf.size += int64(n)
+	return err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	for i := rf.keep - 1; i > 0; i-- {
+		older := fmt.Sprintf("%s.%d", rf.path, i)
+		newer := fmt.Sprintf("%s.%d", rf.path, i-1)
+
+		if _, err := os.Stat(newer); err == nil {
+			os.Rename(newer, older)
+		}
+	}
+
+	if rf.keep > 0 {
+		os.Rename(rf.path, rf.path+".0")
+	}
+
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	rf.file = file
+	rf.size = 0
+
+	return nil
+}
+
+func parseByteSize(raw string) (int64, error) {
+	raw = strings.TrimSpace(strings.ToLower(raw))
+
+	multiplier := int64(1)
+
+	switch {
+	case strings.HasSuffix(raw, "kb"), strings.HasSuffix(raw, "k"):
+		multiplier = 1024
+		raw = strings.TrimSuffix(strings.TrimSuffix(raw, "kb"), "k")
+	case strings.HasSuffix(raw, "mb"), strings.HasSuffix(raw, "m"):
+		multiplier = 1024 * 1024
+		raw = strings.TrimSuffix(strings.TrimSuffix(raw, "mb"), "m")
+	case strings.HasSuffix(raw, "gb"), strings.HasSuffix(raw, "g"):
+		multiplier = 1024 * 1024 * 1024
+		raw = strings.TrimSuffix(strings.TrimSuffix(raw, "gb"), "g")
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", raw, err)
+	}
+
+	return n * multiplier, nil
+}
+
+func newFileSink(spec string) (*sinkRunner, error) {
+	parts := strings.Split(spec, ",")
+	path := parts[0]
+
+	if path == "" {
+		return nil, errors.New("file sink requires a path")
+	}
+
+	maxSize := int64(defaultMaxFileSize)
+	keep := defaultKeepFiles
+
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid file sink option %q", opt)
+		}
+
+		switch kv[0] {
+		case "maxsize":
+			size, err := parseByteSize(kv[1])
+
+			if err != nil {
+				return nil, err
+			}
+
+			maxSize = size
+		case "keep":
+			n, err := strconv.Atoi(kv[1])
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid keep value %q: %w", kv[1], err)
+			}
+
+			keep = n
+		default:
+			return nil, fmt.Errorf("unknown file sink option %q", kv[0])
+		}
+	}
+
+	rf, err := openRotatingFile(path, maxSize, keep)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return startSink(rf.writeLine, rf.file.Close), nil
+}
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"mail":   syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON,
+	"auth":   syslog.LOG_AUTH,
+	"syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+func newSyslogSink(spec string) (*sinkRunner, error) {
+	parsed, err := url.Parse("syslog://" + spec)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog sink %q: %w", spec, err)
+	}
+
+	facility := syslog.LOG_USER
+
+	if name := parsed.Query().Get("facility"); name != "" {
+		f, ok := syslogFacilities[name]
+
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", name)
+		}
+
+		facility = f
+	}
+
+	writer, err := syslog.Dial("udp", parsed.Host, facility|syslog.LOG_INFO, "slowtail")
+
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to syslog at %s: %w", parsed.Host, err)
+	}
+
+	return startSink(func(line string) error {
+		return writer.Info(line)
+	}, writer.Close), nil
+}
+
+const defaultHTTPBatchSize = 50
+const defaultHTTPBatchInterval = 2 * time.Second
+
+func newHTTPSink(spec string) (*sinkRunner, error) {
+	parsed, err := url.Parse(spec)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid http sink %q: %w", spec, err)
 	}
 
-	globalDelay = options.delayMilliseconds
+	batchSize := defaultHTTPBatchSize
+	batchInterval := defaultHTTPBatchInterval
+	query := parsed.Query()
 
-	if options.interactive == true {
-		go interactiveMode(&readyChannel, options.porcelain == true)
-	} else {
-		readyChannel <- true
-	}
+	if raw := query.Get("batch"); raw != "" {
+		n, err := strconv.Atoi(raw)
 
-	if <-readyChannel {
-		if isStdin() {
-			go stdinToChan(os.Stdin, &linesChannel, options.rewindLines)
-		} else {
-			go fileToChan(options.filePath, &linesChannel, options.rewindLines)
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch size %q: %w", raw, err)
 		}
-	}
 
-	for line := range linesChannel {
-		fmt.Println(line)
+		batchSize = n
 	}
-}
-
-func parseArgs(args map[string]interface{}) (arguments, error) {
-	rewindLines := int(0)
-	delayMilliseconds := int(250)
-	filePath := ""
-	porcelain := false
-	interactive := false
 
-	if rewindArg, ok := args["--rewind"].(string); ok {
-		rewindLines, _ = strconv.Atoi(rewindArg)
-	}
+	if raw := query.Get("batchInterval"); raw != "" {
+		d, err := time.ParseDuration(raw)
 
-	if porcelainArg, ok := args["--porcelain"].(bool); ok {
-		porcelain = porcelainArg
-	}
+		if err != nil {
+			return nil, fmt.Errorf("invalid batchInterval %q: %w", raw, err)
+		}
 
-	if interactiveArg, ok := args["--interactive"].(bool); ok {
-		interactive = interactiveArg
+		batchInterval = d
 	}
 
-	if rewindLines < 0 || rewindLines > math.MaxInt32 {
-		return arguments{}, errors.New("--rewind must be a positive number of lines")
-	}
+	postURL := parsed.Scheme + "://" + parsed.Host + parsed.Path
 
-	if delayArg, ok := args["--delay"].(string); ok {
-		delayMilliseconds, _ = strconv.Atoi(delayArg)
-	}
+	batch := make([]string, 0, batchSize)
+	var batchMutex sync.Mutex
 
-	if delayMilliseconds < 0 || delayMilliseconds > math.MaxInt32 {
-		return arguments{}, errors.New("--delay must be a positive number of milliseconds")
-	}
+	flush := func() error {
+		batchMutex.Lock()
 
-	if filePathArg, ok := args["<file>"].(string); ok {
-		filePath = filePathArg
-	}
+		if len(batch) == 0 {
+			batchMutex.Unlock()
+			return nil
+		}
 
-	return arguments{
-		rewindLines,
-		delayMilliseconds,
-		filePath,
-		porcelain,
-		interactive,
-	}, nil
-}
+		payload, err := json.Marshal(batch)
+		batch = make([]string, 0, batchSize)
+		batchMutex.Unlock()
 
-func stdinToChan(source io.Reader, linesChannel *chan string, rewindLinesCount int) {
-	scanner := bufio.NewScanner(source)
+		if err != nil {
+			return err
+		}
 
-	for scanner.Scan() {
-		*linesChannel <- scanner.Text()
+		resp, err := http.Post(postURL, "application/json", bytes.NewReader(payload))
 
-		if rewindLinesCount <= 0 {
-			sleepMilliseconds(globalDelay)
+		if err != nil {
+			return err
 		}
 
-		if rewindLinesCount > 0 {
-			rewindLinesCount--
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("http sink got status %d from %s", resp.StatusCode, postURL)
 		}
+
+		return nil
 	}
+
+	stopTicker := make(chan bool, 1)
+
+	go func() {
+		ticker := time.NewTicker(batchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := flush(); err != nil {
+					fmt.Fprintln(os.Stderr, "slowtail: http sink error:", err)
+				}
+			case <-stopTicker:
+				return
+			}
+		}
+	}()
+
+	return startSink(func(line string) error {
+		batchMutex.Lock()
+		batch = append(batch, line)
+		full := len(batch) >= batchSize
+		batchMutex.Unlock()
+
+		if full {
+			return flush()
+		}
+
+		return nil
+	}, func() error {
+		stopTicker <- true
+		return flush()
+	}), nil
 }
 
-func fileToChan(source string, linesChannel *chan string, rewindLinesCount int) {
-	defer close(*linesChannel)
+func newExecSink(spec string) (*sinkRunner, error) {
+	command := spec
+	batchSize := 1
 
-	if _, err := os.Stat(source); os.IsNotExist(err) {
-		checkErr(err)
+	if idx := strings.Index(spec, "|batch="); idx != -1 {
+		command = spec[:idx]
+
+		n, err := strconv.Atoi(spec[idx+len("|batch="):])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec batch size: %w", err)
+		}
+
+		batchSize = n
 	}
 
-	if rewindLinesCount > 0 {
-		tailFile(source, rewindLinesCount)
+	if command == "" {
+		return nil, errors.New("exec sink requires a command")
 	}
 
-	t, err := tail.TailFile(source, tail.Config{
-		Follow:   true,
-		Poll:     true,
-		Location: &tail.SeekInfo{Offset: 0, Whence: 2},
-		Logger:   tail.DiscardingLogger,
-	})
+	batch := make([]string, 0, batchSize)
 
-	if err != nil {
-		checkErr(err)
-	}
+	runBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
 
-	for line := range t.Lines {
-		*linesChannel <- line.Text
-		sleepMilliseconds(globalDelay)
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = strings.NewReader(strings.Join(batch, "\n") + "\n")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		batch = batch[:0]
+
+		return cmd.Run()
 	}
+
+	return startSink(func(line string) error {
+		batch = append(batch, line)
+
+		if len(batch) >= batchSize {
+			return runBatch()
+		}
+
+		return nil
+	}, runBatch), nil
 }
 
 func eachFileLine(filePath string, callback func(lineNum int, line string) error) (linesRead int, err error) {
@@ -195,14 +1926,14 @@ func eachFileLine(filePath string, callback func(lineNum int, line string) error
 	return lineNum, nil
 }
 
-func tailFile(filePath string, linesCount int) {
+func tailFile(filePath string, linesCount int, emit func(string)) {
 	totalLinesCount, err := eachFileLine(filePath, func(lineNum int, line string) error { return nil })
 	linesToTail := int(math.Abs(float64(linesCount - totalLinesCount)))
 
 	if err == nil {
 		eachFileLine(filePath, func(lineNum int, line string) error {
 			if lineNum >= linesToTail {
-				fmt.Println(line)
+				emit(line)
 			}
 
 			return nil
@@ -212,7 +1943,12 @@ func tailFile(filePath string, linesCount int) {
 	}
 }
 
-func interactiveMode(readyChannel *chan (bool), humanFriendly bool) {
+// interactivePorcelainMode is the original, minimal interactive mode: it only
+// reacts to the arrow keys (and Ctrl+C to quit) and prints a human-friendly status line, leaving
+// the lines themselves to be printed by main's plain stdout loop. It exists
+// for --porcelain, where a full-screen TUI (see interactiveMode) would fight
+// with whatever else is sharing the terminal or consuming the output.
+func interactivePorcelainMode(readyChannel *chan (bool), humanFriendly bool) {
 	err := termbox.Init()
 	if err != nil {
 		checkErr(err)
@@ -244,6 +1980,451 @@ func interactiveMode(readyChannel *chan (bool), humanFriendly bool) {
 	}
 }
 
+// speedPresets backs the 1..9 speed-preset keys in interactiveMode.
+var speedPresets = []int{0, 100, 250, 500, 1000, 2000, 4000, 8000, 16000}
+
+// ringBufferCapacity bounds how many lines interactiveMode keeps in memory so
+// that scrolling back while paused can't grow without limit.
+const ringBufferCapacity = 10000
+
+// uiState is the state behind the split-screen TUI in interactiveMode: the
+// lines seen so far, where the viewport is scrolled to, and the active
+// filter/highlight/command-line input. All access goes through its mutex
+// since it's written by the line-reader goroutine and read/written by the
+// event loop concurrently.
+type uiState struct {
+	mu             sync.Mutex
+	lines          []string
+	viewOffset     int
+	paused         bool
+	filterRegex    *regexp.Regexp
+	highlightRegex *regexp.Regexp
+	linesSeen      int
+	startedAt      time.Time
+	commandMode    rune
+	commandInput   string
+	statusMessage  string
+}
+
+func newUIState() *uiState {
+	return &uiState{startedAt: time.Now()}
+}
+
+// pushLine records an incoming line. While paused the viewport holds its
+// place in the scrollback rather than jumping to show the new line, so the
+// offset grows in lockstep with the buffer.
+func (ui *uiState) pushLine(line string) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.lines = append(ui.lines, line)
+	if len(ui.lines) > ringBufferCapacity {
+		ui.lines = ui.lines[len(ui.lines)-ringBufferCapacity:]
+	}
+	ui.linesSeen++
+
+	if ui.paused && (ui.filterRegex == nil || ui.filterRegex.MatchString(line)) {
+		ui.viewOffset++
+	}
+
+	if ui.viewOffset > len(ui.lines) {
+		ui.viewOffset = len(ui.lines)
+	}
+}
+
+func (ui *uiState) togglePause() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.paused = !ui.paused
+	if !ui.paused {
+		ui.viewOffset = 0
+	}
+}
+
+func (ui *uiState) stepOnce() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if ui.paused && ui.viewOffset > 0 {
+		ui.viewOffset--
+	}
+}
+
+func (ui *uiState) jumpToEnd() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.paused = false
+	ui.viewOffset = 0
+}
+
+func (ui *uiState) scroll(delta int) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.viewOffset += delta
+	if ui.viewOffset < 0 {
+		ui.viewOffset = 0
+	}
+	if ui.viewOffset > 0 {
+		ui.paused = true
+	}
+}
+
+func (ui *uiState) startCommand(mode rune) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.commandMode = mode
+	ui.commandInput = ""
+	ui.statusMessage = ""
+}
+
+func (ui *uiState) inCommandMode() bool {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	return ui.commandMode != 0
+}
+
+func (ui *uiState) appendCommand(ch rune) {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.commandInput += string(ch)
+}
+
+func (ui *uiState) backspaceCommand() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	if len(ui.commandInput) == 0 {
+		return
+	}
+
+	runes := []rune(ui.commandInput)
+	ui.commandInput = string(runes[:len(runes)-1])
+}
+
+func (ui *uiState) cancelCommand() {
+	ui.mu.Lock()
+	defer ui.mu.Unlock()
+
+	ui.commandMode = 0
+	ui.commandInput = ""
+}
+
+// submitCommand compiles the typed regex and, on success, installs it as the
+// filter (`/`) or highlight (`h`) pattern; an empty input clears that pattern
+// instead. A bad regex is reported in the status bar rather than applied.
+func (ui *uiState) submitCommand() {
+	ui.mu.Lock()
+	mode := ui.commandMode
+	input := ui.commandInput
+	ui.commandMode = 0
+	ui.commandInput = ""
+	ui.mu.Unlock()
+
+	if mode == 0 {
+		return
+	}
+
+	var re *regexp.Regexp
+
+	if input != "" {
+		compiled, err := regexp.Compile(input)
+
+		if err != nil {
+			ui.mu.Lock()
+			ui.statusMessage = "invalid regex: " + err.Error()
+			ui.mu.Unlock()
+			return
+		}
+
+		re = compiled
+	}
+
+	ui.mu.Lock()
+	if mode == '/' {
+		ui.filterRegex = re
+		ui.viewOffset = 0
+	} else {
+		ui.highlightRegex = re
+	}
+	ui.statusMessage = ""
+	ui.mu.Unlock()
+}
+
+func (ui *uiState) setDelayPreset(index int) {
+	if index < 0 || index >= len(speedPresets) {
+		return
+	}
+
+	globalDelayMutex.Lock()
+	globalDelay = speedPresets[index]
+	globalDelayMutex.Unlock()
+}
+
+// visibleLinesLocked returns the slice of (possibly filtered) lines that
+// should occupy a log pane of the given height, given the current scroll
+// offset. Caller must hold ui.mu.
+func (ui *uiState) visibleLinesLocked(height int) []string {
+	filtered := ui.lines
+
+	if ui.filterRegex != nil {
+		filtered = make([]string, 0, len(ui.lines))
+
+		for _, line := range ui.lines {
+			if ui.filterRegex.MatchString(line) {
+				filtered = append(filtered, line)
+			}
+		}
+	}
+
+	offset := ui.viewOffset
+	if offset > len(filtered) {
+		offset = len(filtered)
+	}
+
+	end := len(filtered) - offset
+	if end < 0 {
+		end = 0
+	}
+
+	start := end - height
+	if start < 0 {
+		start = 0
+	}
+
+	return filtered[start:end]
+}
+
+// statusTextLocked renders the status bar: pause state, current delay,
+// throughput, and total lines seen. Caller must hold ui.mu.
+func (ui *uiState) statusTextLocked() string {
+	state := "live"
+	if ui.paused {
+		state = "paused"
+	}
+
+	elapsed := time.Since(ui.startedAt).Seconds()
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(ui.linesSeen) / elapsed
+	}
+
+	globalDelayMutex.Lock()
+	delay := globalDelay
+	globalDelayMutex.Unlock()
+
+	message := ""
+	if ui.statusMessage != "" {
+		message = " — " + ui.statusMessage
+	}
+
+	return fmt.Sprintf(" %s | delay: %dms | %.1f lines/s | seen: %d%s", state, delay, rate, ui.linesSeen, message)
+}
+
+// commandTextLocked renders the bottom command line: either the key-binding
+// hint, or whatever the user is currently typing into a filter/highlight
+// prompt. Caller must hold ui.mu.
+func (ui *uiState) commandTextLocked() string {
+	if ui.commandMode == 0 {
+		return " space:pause  n:step  G:end  /:filter  h:highlight  1-9:speed  PgUp/PgDn:scroll  ^C:quit"
+	}
+
+	return " " + string(ui.commandMode) + ui.commandInput
+}
+
+// interactiveMode is the rich split-screen TUI: a scrolling log pane backed
+// by a bounded ring buffer, a status bar, and a command line for regex
+// filter/highlight input. Lines arrive on linesChannel and are consumed here
+// instead of being printed by main, so the whole terminal belongs to this
+// loop until Ctrl+C signals doneChannel.
+func interactiveMode(readyChannel *chan bool, linesChannel *chan string, doneChannel *chan bool) {
+	err := termbox.Init()
+	if err != nil {
+		checkErr(err)
+	}
+
+	termbox.SetInputMode(termbox.InputEsc)
+
+	ui := newUIState()
+	redraw := make(chan bool, 1)
+
+	requestRedraw := func() {
+		select {
+		case redraw <- true:
+		default:
+		}
+	}
+
+	*readyChannel <- true
+
+	go func() {
+		for line := range *linesChannel {
+			ui.pushLine(line)
+			requestRedraw()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			requestRedraw()
+		}
+	}()
+
+	events := make(chan termbox.Event)
+	go func() {
+		for {
+			events <- termbox.PollEvent()
+		}
+	}()
+
+	renderUI(ui)
+
+	for {
+		select {
+		case ev := <-events:
+			switch ev.Type {
+			case termbox.EventKey:
+				if handleUIKey(ui, ev) {
+					termbox.Close()
+					*doneChannel <- true
+					return
+				}
+			case termbox.EventError:
+				checkErr(ev.Err)
+			}
+
+			renderUI(ui)
+		case <-redraw:
+			renderUI(ui)
+		}
+	}
+}
+
+// handleUIKey applies a single key event to ui and reports whether the user
+// asked to quit (Ctrl+C).
+func handleUIKey(ui *uiState, ev termbox.Event) bool {
+	_, height := termbox.Size()
+	pageSize := height - 2
+	if pageSize < 1 {
+		pageSize = 1
+	}
+
+	if ui.inCommandMode() {
+		switch ev.Key {
+		case termbox.KeyEsc:
+			ui.cancelCommand()
+		case termbox.KeyEnter:
+			ui.submitCommand()
+		case termbox.KeyBackspace, termbox.KeyBackspace2:
+			ui.backspaceCommand()
+		case termbox.KeySpace:
+			ui.appendCommand(' ')
+		default:
+			if ev.Ch != 0 {
+				ui.appendCommand(ev.Ch)
+			}
+		}
+
+		return false
+	}
+
+	switch ev.Key {
+	case termbox.KeyCtrlC:
+		return true
+	case termbox.KeySpace:
+		ui.togglePause()
+		return false
+	case termbox.KeyPgup:
+		ui.scroll(pageSize)
+		return false
+	case termbox.KeyPgdn:
+		ui.scroll(-pageSize)
+		return false
+	case termbox.KeyArrowDown:
+		changeSpeed(true, false)
+		return false
+	case termbox.KeyArrowUp:
+		changeSpeed(false, false)
+		return false
+	}
+
+	switch ev.Ch {
+	case 'n':
+		ui.stepOnce()
+	case 'G':
+		ui.jumpToEnd()
+	case '/':
+		ui.startCommand('/')
+	case 'h':
+		ui.startCommand('h')
+	default:
+		if ev.Ch >= '1' && ev.Ch <= '9' {
+			ui.setDelayPreset(int(ev.Ch - '1'))
+		}
+	}
+
+	return false
+}
+
+// renderUI redraws the whole screen: the log pane, the status bar, and the
+// command line, in that order from top to bottom.
+func renderUI(ui *uiState) {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	width, height := termbox.Size()
+
+	logHeight := height - 2
+	if logHeight < 0 {
+		logHeight = 0
+	}
+
+	ui.mu.Lock()
+	visible := ui.visibleLinesLocked(logHeight)
+	highlight := ui.highlightRegex
+	statusText := ui.statusTextLocked()
+	commandText := ui.commandTextLocked()
+	ui.mu.Unlock()
+
+	for row, line := range visible {
+		fg := termbox.ColorDefault
+
+		if highlight != nil && highlight.MatchString(line) {
+			fg = termbox.ColorYellow | termbox.AttrBold
+		}
+
+		writeUILine(0, row, width, line, fg, termbox.ColorDefault)
+	}
+
+	writeUILine(0, logHeight, width, statusText, termbox.ColorBlack, termbox.ColorWhite)
+	writeUILine(0, logHeight+1, width, commandText, termbox.ColorDefault, termbox.ColorDefault)
+
+	termbox.Flush()
+}
+
+func writeUILine(x, y, width int, text string, fg termbox.Attribute, bg termbox.Attribute) {
+	col := x
+
+	for _, r := range text {
+		if col >= width {
+			break
+		}
+
+		termbox.SetCell(col, y, r, fg, bg)
+		col++
+	}
+
+	for ; col < width; col++ {
+		termbox.SetCell(col, y, ' ', fg, bg)
+	}
+}
+
 func speedMessage(down bool) string {
 	direction := "faster"
 	if down == true {
@@ -283,11 +2464,11 @@ func speedMessage(down bool) string {
 
 func changeSpeed(down bool, humanFriendly bool) {
 	if down {
+		globalDelayMutex.Lock()
 		if globalDelay < math.MaxInt32-250 {
-			globalDelayMutex.Lock()
 			globalDelay += 250
-			globalDelayMutex.Unlock()
 		}
+		globalDelayMutex.Unlock()
 	} else {
 		globalDelayMutex.Lock()
 		if globalDelay-250 >= 0 {
@@ -313,16 +2494,6 @@ func quitInteracitve(humanFriendly bool) {
 	os.Stdin.Close()
 }
 
-func isStdin() bool {
-	stat, _ := os.Stdin.Stat()
-
-	if (stat.Mode() & os.ModeCharDevice) == os.ModeCharDevice {
-		return false
-	}
-
-	return true
-}
-
 func checkErr(err error) {
 	if err != nil {
 		log.Fatal(color.RedString("ERROR: "), color.RedString(err.Error()))